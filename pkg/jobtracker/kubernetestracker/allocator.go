@@ -0,0 +1,145 @@
+package kubernetestracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os/pkg/jobtracker"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// K8S_JT_EXTENSION_WORKLOAD_KIND selects which WorkloadKindDriver AddJob
+// submits a JobTemplate through, e.g. "kubeflow.org/TFJob" or
+// "batch.volcano.sh/Job". Templates without this extension keep using the
+// tracker's built-in batchv1.Job (plus CronJob and JobSet) handling.
+const K8S_JT_EXTENSION_WORKLOAD_KIND = "workloadKind"
+
+// defaultWorkloadKind is the key of the built-in batchv1.Job driver.
+const defaultWorkloadKind = "batch/Job"
+
+// WorkloadKindDriver lets KubernetesTracker submit and track workload kinds
+// other than batchv1.Job (e.g. Kubeflow's TFJob, PyTorchJob, MPIJob, or a
+// Volcano Job) through the dynamic client, keyed by a JobTemplate's
+// K8S_JT_EXTENSION_WORKLOAD_KIND extension.
+type WorkloadKindDriver interface {
+	// Convert turns a JobTemplate into the driver's custom resource.
+	Convert(jt drmaa2interface.JobTemplate) (runtime.Object, error)
+	// State returns the DRMAA2 state of the named resource, or
+	// drmaa2interface.Undetermined if no such resource exists.
+	State(name string) drmaa2interface.JobState
+	// Delete removes the named resource.
+	Delete(name string) error
+	// Watch opens a watch on resources of this kind.
+	Watch(name string) watch.Interface
+	// GVK identifies the Kubernetes kind this driver manages.
+	GVK() schema.GroupVersionKind
+}
+
+// workloadKindKey derives the K8S_JT_EXTENSION_WORKLOAD_KIND value a driver
+// is registered under from its GVK, e.g. {Group: "kubeflow.org", Kind:
+// "TFJob"} becomes "kubeflow.org/TFJob".
+func workloadKindKey(gvk schema.GroupVersionKind) string {
+	group := gvk.Group
+	if group == "" {
+		group = "batch"
+	}
+	return fmt.Sprintf("%s/%s", group, gvk.Kind)
+}
+
+// allocator is registered at the DRMAA2 SessionManager for
+// drmaa2os.KubernetesSession and creates a KubernetesTracker per JobSession.
+type allocator struct {
+	mu              sync.Mutex
+	drivers         map[string]WorkloadKindDriver
+	validators      []JobTemplateValidator
+	podSpecMutators []PodSpecMutator
+}
+
+// NewAllocator returns an allocator for drmaa2os.KubernetesSession. Extra
+// drivers are registered under the workloadKind key derived from their GVK
+// (see workloadKindKey) so that submitting a distributed training job (or
+// any other CRD-backed workload) through DRMAA2 is a matter of setting the
+// K8S_JT_EXTENSION_WORKLOAD_KIND extension. batchv1.Job (defaultWorkloadKind),
+// CronJob and JobSet submissions are not driven through a WorkloadKindDriver;
+// they always use the tracker's built-in handling.
+func NewAllocator(drivers ...WorkloadKindDriver) *allocator {
+	a := &allocator{drivers: map[string]WorkloadKindDriver{}}
+	for _, d := range drivers {
+		a.drivers[workloadKindKey(d.GVK())] = d
+	}
+	return a
+}
+
+// New is called by the SessionManager when a new JobSession is allocated.
+// jobTrackerInitParams must be a kubernetes.Clientset if not nil. If nil
+// a new Clientset is allocated.
+func (a *allocator) New(jobSessionName string, jobTrackerInitParams interface{}) (jobtracker.JobTracker, error) {
+	var cs *kubernetes.Clientset
+	if jobTrackerInitParams != nil {
+		var ok bool
+		cs, ok = jobTrackerInitParams.(*kubernetes.Clientset)
+		if !ok {
+			return nil, errors.New("jobTrackerInitParams is not of type *kubernetes.Clientset")
+		}
+	}
+	kt, err := New(jobSessionName, cs)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	for kind, d := range a.drivers {
+		kt.drivers[kind] = d
+	}
+	kt.validators = append(kt.validators, a.validators...)
+	kt.podSpecMutators = append(kt.podSpecMutators, a.podSpecMutators...)
+	a.mu.Unlock()
+
+	return kt, nil
+}
+
+// NewDynamicClient builds a dynamic.Interface the same way NewClientSet
+// builds a *kubernetes.Clientset: from the in-cluster config when running
+// inside a pod, falling back to KUBECONFIG / $HOME/.kube/config otherwise.
+// It is used by drivers (e.g. NewTFJobDriver) which address CRDs that have
+// no generated typed client.
+func NewDynamicClient() (dynamic.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		kubeconfig := os.Getenv("KUBECONFIG")
+		if kubeconfig == "" {
+			home, herr := os.UserHomeDir()
+			if herr != nil {
+				return nil, fmt.Errorf("determining kubeconfig path: %s", herr.Error())
+			}
+			kubeconfig = filepath.Join(home, ".kube", "config")
+		}
+		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("building kubernetes client config: %s", err.Error())
+		}
+	}
+	return dynamic.NewForConfig(cfg)
+}
+
+// gvrForKind derives the resource name (plural) for a GVK using the common
+// "lowercase kind + s" convention used by most CRDs this package targets
+// (TFJob -> tfjobs, Job -> jobs).
+func gvrForKind(gvk schema.GroupVersionKind) schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    gvk.Group,
+		Version:  gvk.Version,
+		Resource: strings.ToLower(gvk.Kind) + "s",
+	}
+}
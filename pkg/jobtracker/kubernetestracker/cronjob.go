@@ -0,0 +1,233 @@
+package kubernetestracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	batchv1client "k8s.io/client-go/kubernetes/typed/batch/v1"
+)
+
+// k8sDefaultNamespace is used whenever a Kubernetes object is addressed
+// without an explicit K8S_JT_EXTENSION_NAMESPACE extension.
+const k8sDefaultNamespace = "default"
+
+// Extensions which select and configure the CronJob submission path. A
+// JobTemplate carrying K8S_JT_EXTENSION_CRON_SCHEDULE is submitted as a
+// batchv1.CronJob instead of a one-shot batchv1.Job.
+const K8S_JT_EXTENSION_CRON_SCHEDULE = "schedule"
+const K8S_JT_EXTENSION_CRON_CONCURRENCY_POLICY = "concurrencyPolicy"
+const K8S_JT_EXTENSION_CRON_STARTING_DEADLINE_SECONDS = "startingDeadlineSeconds"
+const K8S_JT_EXTENSION_CRON_SUCCESSFUL_JOBS_HISTORY_LIMIT = "successfulJobsHistoryLimit"
+const K8S_JT_EXTENSION_CRON_FAILED_JOBS_HISTORY_LIMIT = "failedJobsHistoryLimit"
+const K8S_JT_EXTENSION_CRON_SUSPEND = "suspend"
+
+// drmaa2kindLabel is set to drmaa2kindCronJob on CronJobs created by AddJob
+// (and on the Jobs they spawn); one-shot Jobs do not carry it. Operations
+// taking only a job ID do not read this label: resolveJobKind probes the
+// Jobs and CronJobs clients and returns drmaa2kindJob or drmaa2kindCronJob.
+const drmaa2kindLabel = "drmaa2kind"
+const drmaa2kindJob = "job"
+const drmaa2kindCronJob = "cronjob"
+
+// isCronJobTemplate reports whether jt requests the scheduled-job submission
+// path, i.e. it carries a cron schedule extension.
+func isCronJobTemplate(jt drmaa2interface.JobTemplate) bool {
+	_, exists := jt.ExtensionList[K8S_JT_EXTENSION_CRON_SCHEDULE]
+	return exists
+}
+
+// convertToCronJob converts jt into a batchv1.CronJob by first building the
+// regular Job spec (via convertJob) and wrapping it into a JobTemplateSpec.
+func convertToCronJob(jobsession string, jt drmaa2interface.JobTemplate) (*batchv1.CronJob, error) {
+	schedule, exists := jt.ExtensionList[K8S_JT_EXTENSION_CRON_SCHEDULE]
+	if !exists || schedule == "" {
+		return nil, fmt.Errorf("extension %s not set", K8S_JT_EXTENSION_CRON_SCHEDULE)
+	}
+
+	job, err := convertJob(jobsession, jt)
+	if err != nil {
+		return nil, err
+	}
+	job.Labels[drmaa2kindLabel] = drmaa2kindCronJob
+	if err := applyTTLExtension(job, jt); err != nil {
+		return nil, fmt.Errorf("applying %s extension: %s", K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED, err.Error())
+	}
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: job.ObjectMeta,
+		Spec: batchv1.CronJobSpec{
+			Schedule: schedule,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: job.ObjectMeta,
+				Spec:       job.Spec,
+			},
+		},
+	}
+	// the job itself must not be created - only the CronJob is submitted -
+	// clear the name so Kubernetes generates a fresh name for each run
+	cronJob.Spec.JobTemplate.ObjectMeta.Name = ""
+
+	if policy, exists := jt.ExtensionList[K8S_JT_EXTENSION_CRON_CONCURRENCY_POLICY]; exists {
+		cronJob.Spec.ConcurrencyPolicy = batchv1.ConcurrencyPolicy(policy)
+	}
+	if v, exists := jt.ExtensionList[K8S_JT_EXTENSION_CRON_STARTING_DEADLINE_SECONDS]; exists {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", K8S_JT_EXTENSION_CRON_STARTING_DEADLINE_SECONDS, err.Error())
+		}
+		cronJob.Spec.StartingDeadlineSeconds = &seconds
+	}
+	if v, exists := jt.ExtensionList[K8S_JT_EXTENSION_CRON_SUCCESSFUL_JOBS_HISTORY_LIMIT]; exists {
+		limit, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", K8S_JT_EXTENSION_CRON_SUCCESSFUL_JOBS_HISTORY_LIMIT, err.Error())
+		}
+		l := int32(limit)
+		cronJob.Spec.SuccessfulJobsHistoryLimit = &l
+	}
+	if v, exists := jt.ExtensionList[K8S_JT_EXTENSION_CRON_FAILED_JOBS_HISTORY_LIMIT]; exists {
+		limit, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", K8S_JT_EXTENSION_CRON_FAILED_JOBS_HISTORY_LIMIT, err.Error())
+		}
+		l := int32(limit)
+		cronJob.Spec.FailedJobsHistoryLimit = &l
+	}
+	if v, exists := jt.ExtensionList[K8S_JT_EXTENSION_CRON_SUSPEND]; exists {
+		suspend := v == "true" || v == "1"
+		cronJob.Spec.Suspend = &suspend
+	}
+
+	return cronJob, nil
+}
+
+// getCronJobsClient returns the CronJobs client, mirroring getJobsClient's
+// namespace handling.
+func getCronJobsClient(kt *KubernetesTracker) (batchv1client.CronJobInterface, error) {
+	if kt.clientSet == nil {
+		return nil, errors.New("clientSet is not set")
+	}
+	return kt.clientSet.BatchV1().CronJobs(k8sDefaultNamespace), nil
+}
+
+// addCronJob creates the CronJob derived from jt and returns its name.
+func (kt *KubernetesTracker) addCronJob(jt drmaa2interface.JobTemplate) (string, error) {
+	cronJob, err := convertToCronJob(kt.jobsession, jt)
+	if err != nil {
+		return "", fmt.Errorf("converting job template into a k8s cron job: %s", err.Error())
+	}
+	tmp := &batchv1.Job{ObjectMeta: cronJob.Spec.JobTemplate.ObjectMeta, Spec: cronJob.Spec.JobTemplate.Spec}
+	if err := kt.runPodSpecMutators(tmp); err != nil {
+		return "", fmt.Errorf("mutating cron job: %s", err.Error())
+	}
+	cronJob.Spec.JobTemplate.ObjectMeta = tmp.ObjectMeta
+	cronJob.Spec.JobTemplate.Spec = tmp.Spec
+
+	cjc, err := getCronJobsClient(kt)
+	if err != nil {
+		return "", fmt.Errorf("get client: %s", err.Error())
+	}
+	cj, err := cjc.Create(context.TODO(), cronJob, k8sapi.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("creating new cron job: %s", err.Error())
+	}
+	return string(cj.Name), nil
+}
+
+// resolveJobKind determines whether jobid refers to a batchv1.Job or a
+// batchv1.CronJob by probing both clients.
+func resolveJobKind(kt *KubernetesTracker, jobid string) (string, error) {
+	jc, err := getJobsClient(kt.clientSet)
+	if err == nil {
+		if _, err := jc.Get(context.TODO(), jobid, k8sapi.GetOptions{}); err == nil {
+			return drmaa2kindJob, nil
+		}
+	}
+	cjc, err := getCronJobsClient(kt)
+	if err != nil {
+		return "", err
+	}
+	if _, err := cjc.Get(context.TODO(), jobid, k8sapi.GetOptions{}); err == nil {
+		return drmaa2kindCronJob, nil
+	} else if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	return "", fmt.Errorf("job %s not found", jobid)
+}
+
+// cronJobState maps a batchv1.CronJob onto a drmaa2interface.JobState. A
+// suspended CronJob is reported as Suspended (even while a run is active),
+// a CronJob with an active run as Running, and any other existing CronJob
+// as Queued, since it has no terminal state of its own. A CronJob that
+// cannot be fetched is Undetermined.
+func cronJobState(cjc batchv1client.CronJobInterface, jobid string) drmaa2interface.JobState {
+	cj, err := cjc.Get(context.TODO(), jobid, k8sapi.GetOptions{})
+	if err != nil {
+		return drmaa2interface.Undetermined
+	}
+	if cj.Spec.Suspend != nil && *cj.Spec.Suspend {
+		return drmaa2interface.Suspended
+	}
+	if len(cj.Status.Active) > 0 {
+		return drmaa2interface.Running
+	}
+	return drmaa2interface.Queued
+}
+
+// CronJobToJobInfo converts a batchv1.CronJob into a drmaa2interface.JobInfo,
+// mirroring JobToJobInfo for the recurring-job case.
+func CronJobToJobInfo(cjc batchv1client.CronJobInterface, jobid string) (drmaa2interface.JobInfo, error) {
+	cj, err := cjc.Get(context.TODO(), jobid, k8sapi.GetOptions{})
+	if err != nil {
+		return drmaa2interface.JobInfo{}, fmt.Errorf("getting cron job %s: %s", jobid, err.Error())
+	}
+	jobInfo := drmaa2interface.JobInfo{
+		ID:    jobid,
+		State: cronJobState(cjc, jobid),
+	}
+	if cj.Status.LastScheduleTime != nil {
+		jobInfo.DispatchTime = cj.Status.LastScheduleTime.Time
+	}
+	if cj.Status.LastSuccessfulTime != nil {
+		jobInfo.FinishTime = cj.Status.LastSuccessfulTime.Time
+	}
+	return jobInfo, nil
+}
+
+// deleteCronJob removes the CronJob and, through Kubernetes garbage
+// collection, any Jobs it has spawned.
+func deleteCronJob(cjc batchv1client.CronJobInterface, jobid string) error {
+	propagation := k8sapi.DeletePropagationForeground
+	err := cjc.Delete(context.TODO(), jobid, k8sapi.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		return fmt.Errorf("deleting cron job %s: %s", jobid, err.Error())
+	}
+	return nil
+}
+
+// cronJobControl flips spec.suspend on the CronJob for suspend/resume
+// actions instead of erroring out like a one-shot Job would.
+func cronJobControl(cjc batchv1client.CronJobInterface, jobid, state string) error {
+	cj, err := cjc.Get(context.TODO(), jobid, k8sapi.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting cron job %s: %s", jobid, err.Error())
+	}
+	switch state {
+	case "suspend":
+		suspend := true
+		cj.Spec.Suspend = &suspend
+	case "resume":
+		suspend := false
+		cj.Spec.Suspend = &suspend
+	default:
+		return fmt.Errorf("job control state %s is not supported for cron jobs", state)
+	}
+	_, err = cjc.Update(context.TODO(), cj, k8sapi.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,70 @@
+package kubernetestracker
+
+import (
+	"testing"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+func TestIsCronJobTemplate(t *testing.T) {
+	if isCronJobTemplate(drmaa2interface.JobTemplate{}) {
+		t.Fatal("a template without the schedule extension must not be treated as a cron job")
+	}
+	jt := drmaa2interface.JobTemplate{
+		ExtensionList: map[string]string{K8S_JT_EXTENSION_CRON_SCHEDULE: "* * * * *"},
+	}
+	if !isCronJobTemplate(jt) {
+		t.Fatal("a template carrying the schedule extension must be treated as a cron job")
+	}
+}
+
+func TestConvertToCronJobRequiresSchedule(t *testing.T) {
+	jt := drmaa2interface.JobTemplate{JobCategory: "busybox"}
+	if _, err := convertToCronJob("session1", jt); err == nil {
+		t.Fatal("expected an error when the schedule extension is missing")
+	}
+}
+
+func TestConvertToCronJobAppliesOptionalExtensions(t *testing.T) {
+	jt := drmaa2interface.JobTemplate{
+		JobCategory: "busybox",
+		ExtensionList: map[string]string{
+			K8S_JT_EXTENSION_CRON_SCHEDULE:                      "*/5 * * * *",
+			K8S_JT_EXTENSION_CRON_CONCURRENCY_POLICY:            "Forbid",
+			K8S_JT_EXTENSION_CRON_STARTING_DEADLINE_SECONDS:     "30",
+			K8S_JT_EXTENSION_CRON_SUCCESSFUL_JOBS_HISTORY_LIMIT: "2",
+			K8S_JT_EXTENSION_CRON_FAILED_JOBS_HISTORY_LIMIT:     "1",
+			K8S_JT_EXTENSION_CRON_SUSPEND:                       "true",
+		},
+	}
+
+	cronJob, err := convertToCronJob("session1", jt)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if cronJob.Spec.Schedule != "*/5 * * * *" {
+		t.Fatalf("Schedule = %q, want %q", cronJob.Spec.Schedule, "*/5 * * * *")
+	}
+	if cronJob.Spec.ConcurrencyPolicy != batchv1.ConcurrencyPolicy("Forbid") {
+		t.Fatalf("ConcurrencyPolicy = %v, want Forbid", cronJob.Spec.ConcurrencyPolicy)
+	}
+	if cronJob.Spec.StartingDeadlineSeconds == nil || *cronJob.Spec.StartingDeadlineSeconds != 30 {
+		t.Fatal("StartingDeadlineSeconds = nil or wrong value, want 30")
+	}
+	if cronJob.Spec.SuccessfulJobsHistoryLimit == nil || *cronJob.Spec.SuccessfulJobsHistoryLimit != 2 {
+		t.Fatal("SuccessfulJobsHistoryLimit = nil or wrong value, want 2")
+	}
+	if cronJob.Spec.FailedJobsHistoryLimit == nil || *cronJob.Spec.FailedJobsHistoryLimit != 1 {
+		t.Fatal("FailedJobsHistoryLimit = nil or wrong value, want 1")
+	}
+	if cronJob.Spec.Suspend == nil || !*cronJob.Spec.Suspend {
+		t.Fatal("Suspend = nil or false, want true")
+	}
+	if cronJob.Labels[drmaa2kindLabel] != drmaa2kindCronJob {
+		t.Fatalf("%s label = %q, want %q", drmaa2kindLabel, cronJob.Labels[drmaa2kindLabel], drmaa2kindCronJob)
+	}
+	if cronJob.Spec.JobTemplate.ObjectMeta.Name != "" {
+		t.Fatal("the embedded JobTemplateSpec must not carry a fixed name - Kubernetes generates one per run")
+	}
+}
@@ -0,0 +1,217 @@
+package kubernetestracker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dgruber/drmaa2interface"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// tfJobDriver submits Kubeflow TFJobs through the dynamic client, so that a
+// distributed TensorFlow training job can be requested through DRMAA2 by
+// setting K8S_JT_EXTENSION_WORKLOAD_KIND to "kubeflow.org/TFJob".
+//
+// It only maps the handful of JobTemplate fields that carry over to a TFJob
+// (the image via JobCategory, the command via RemoteCommand/Args, and the
+// worker replica count via the K8S_JT_EXTENSION_TFJOB_WORKERS extension).
+// No other ExtensionList entries are passed through to the TFJob, and
+// PodSpecMutators are not applied to it.
+type tfJobDriver struct {
+	dynamicClient dynamic.Interface
+	namespace     string
+}
+
+// K8S_JT_EXTENSION_TFJOB_WORKERS sets the number of Worker replicas of a
+// TFJob submitted through tfJobDriver. Defaults to 1 if unset.
+const K8S_JT_EXTENSION_TFJOB_WORKERS = "tfjobWorkers"
+
+var tfJobGVR = schema.GroupVersionResource{Group: "kubeflow.org", Version: "v1", Resource: "tfjobs"}
+var tfJobGVK = schema.GroupVersionKind{Group: "kubeflow.org", Version: "v1", Kind: "TFJob"}
+
+// NewTFJobDriver returns a WorkloadKindDriver submitting Kubeflow TFJobs
+// through dynClient, to be passed to NewAllocator.
+func NewTFJobDriver(dynClient dynamic.Interface, namespace string) WorkloadKindDriver {
+	if namespace == "" {
+		namespace = k8sDefaultNamespace
+	}
+	return &tfJobDriver{dynamicClient: dynClient, namespace: namespace}
+}
+
+func (d *tfJobDriver) Convert(jt drmaa2interface.JobTemplate) (runtime.Object, error) {
+	if jt.JobCategory == "" {
+		return nil, fmt.Errorf("tfJobDriver: JobCategory (container image) must be set")
+	}
+	workers := int64(1)
+	if v, exists := jt.ExtensionList[K8S_JT_EXTENSION_TFJOB_WORKERS]; exists {
+		var err error
+		if workers, err = parseInt64(v); err != nil {
+			return nil, fmt.Errorf("tfJobDriver: parsing %s: %s", K8S_JT_EXTENSION_TFJOB_WORKERS, err.Error())
+		}
+	}
+
+	container := map[string]interface{}{
+		"name":  "tensorflow",
+		"image": jt.JobCategory,
+	}
+	if jt.RemoteCommand != "" {
+		container["command"] = append([]string{jt.RemoteCommand}, jt.Args...)
+	}
+
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": tfJobGVK.GroupVersion().String(),
+			"kind":       tfJobGVK.Kind,
+			"metadata": map[string]interface{}{
+				"generateName": "drmaa2tfjob-",
+				"namespace":    d.namespace,
+			},
+			"spec": map[string]interface{}{
+				"tfReplicaSpecs": map[string]interface{}{
+					"Worker": map[string]interface{}{
+						"replicas": workers,
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"containers":    []interface{}{container},
+								"restartPolicy": "OnFailure",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	return obj, nil
+}
+
+func (d *tfJobDriver) State(name string) drmaa2interface.JobState {
+	obj, err := d.dynamicClient.Resource(tfJobGVR).Namespace(d.namespace).Get(context.TODO(), name, k8sapi.GetOptions{})
+	if err != nil {
+		return drmaa2interface.Undetermined
+	}
+	conditions, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil || !found {
+		return drmaa2interface.Queued
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["status"] != "True" {
+			continue
+		}
+		switch condition["type"] {
+		case "Succeeded":
+			return drmaa2interface.Done
+		case "Failed":
+			return drmaa2interface.Failed
+		case "Running":
+			return drmaa2interface.Running
+		}
+	}
+	return drmaa2interface.Queued
+}
+
+func (d *tfJobDriver) Delete(name string) error {
+	return d.dynamicClient.Resource(tfJobGVR).Namespace(d.namespace).Delete(context.TODO(), name, k8sapi.DeleteOptions{})
+}
+
+func (d *tfJobDriver) Watch(name string) watch.Interface {
+	w, err := d.dynamicClient.Resource(tfJobGVR).Namespace(d.namespace).Watch(context.TODO(), k8sapi.ListOptions{
+		FieldSelector: "metadata.name=" + name,
+	})
+	if err != nil {
+		return watch.NewEmptyWatch()
+	}
+	return w
+}
+
+func (d *tfJobDriver) GVK() schema.GroupVersionKind {
+	return tfJobGVK
+}
+
+func parseInt64(v string) (int64, error) {
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// toUnstructured converts a WorkloadKindDriver.Convert result into an
+// *unstructured.Unstructured so addViaDriver can submit it through the
+// dynamic client. Drivers backed by CRDs with no generated typed client
+// (e.g. tfJobDriver) already return one directly; drivers backed by a
+// typed client-go API object go through runtime's generic converter
+// instead, with gvk filled in if the object's own TypeMeta is empty (as it
+// typically is for objects built for a typed client, which never needs it).
+func toUnstructured(obj runtime.Object, gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	if u.GetAPIVersion() == "" || u.GetKind() == "" {
+		u.SetGroupVersionKind(gvk)
+	}
+	return u, nil
+}
+
+// driverOwning returns the non-default WorkloadKindDriver that reports
+// owning jobid, if any.
+func (kt *KubernetesTracker) driverOwning(jobid string) (WorkloadKindDriver, bool) {
+	for kind, driver := range kt.drivers {
+		if kind == defaultWorkloadKind {
+			continue
+		}
+		if driver.State(jobid) != drmaa2interface.Undetermined {
+			return driver, true
+		}
+	}
+	return nil, false
+}
+
+// addViaDriver submits jt through the WorkloadKindDriver registered under
+// kind, stamping the shared drmaa2jobsession label onto the generated
+// object (generically, since WorkloadKindDriver.Convert does not know the
+// tracker's job session) before creating it through the dynamic client.
+func (kt *KubernetesTracker) addViaDriver(kind string, jt drmaa2interface.JobTemplate) (string, error) {
+	driver, ok := kt.drivers[kind]
+	if !ok {
+		return "", fmt.Errorf("no driver registered for workloadKind %q", kind)
+	}
+	obj, err := driver.Convert(jt)
+	if err != nil {
+		return "", fmt.Errorf("converting job template via %s driver: %s", kind, err.Error())
+	}
+	u, err := toUnstructured(obj, driver.GVK())
+	if err != nil {
+		return "", fmt.Errorf("converting %s driver's %T into unstructured: %s", kind, obj, err.Error())
+	}
+	labels := u.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["drmaa2jobsession"] = kt.jobsession
+	u.SetLabels(labels)
+
+	dc, err := kt.dynamicClient()
+	if err != nil {
+		return "", fmt.Errorf("getting dynamic client: %s", err.Error())
+	}
+	namespace := u.GetNamespace()
+	if namespace == "" {
+		namespace = k8sDefaultNamespace
+	}
+	created, err := dc.Resource(gvrForKind(driver.GVK())).Namespace(namespace).Create(context.TODO(), u, k8sapi.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("creating %s resource: %s", kind, err.Error())
+	}
+	return created.GetName(), nil
+}
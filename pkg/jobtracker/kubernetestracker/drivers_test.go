@@ -0,0 +1,37 @@
+package kubernetestracker
+
+import (
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestToUnstructuredPassesThroughUnstructured(t *testing.T) {
+	in := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "TFJob"}}
+	out, err := toUnstructured(in, schema.GroupVersionKind{Group: "kubeflow.org", Version: "v1", Kind: "TFJob"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if out != in {
+		t.Fatal("an already-unstructured object should be returned unchanged, not re-converted")
+	}
+}
+
+func TestToUnstructuredConvertsTypedClientObject(t *testing.T) {
+	job := &batchv1.Job{ObjectMeta: k8sapi.ObjectMeta{Name: "myjob"}}
+	gvk := batchv1.SchemeGroupVersion.WithKind("Job")
+
+	u, err := toUnstructured(job, gvk)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if u.GetName() != "myjob" {
+		t.Fatalf("GetName() = %q, want %q", u.GetName(), "myjob")
+	}
+	if got := u.GroupVersionKind(); got != gvk {
+		t.Fatalf("GroupVersionKind() = %v, want %v (a typed object with no TypeMeta of its own must fall back to the driver's GVK)", got, gvk)
+	}
+}
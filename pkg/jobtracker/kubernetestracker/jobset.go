@@ -0,0 +1,285 @@
+package kubernetestracker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// drmaa2jobsetLabel groups the batchv1.Job objects (and the headless
+// Service used for pod DNS) that make up a single AddJobSet submission.
+const drmaa2jobsetLabel = "drmaa2jobset"
+
+// JobSetPolicy configures how a job set created through AddJobSet is
+// evaluated as a single entity.
+type JobSetPolicy struct {
+	// FailFast reports the set as Failed as soon as any member job fails,
+	// rather than waiting for all members to reach a terminal state.
+	FailFast bool
+}
+
+// newJobSetGUID generates the identifier shared by all jobs and the
+// headless service belonging to one AddJobSet submission.
+func newJobSetGUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("jobset-%x", b), nil
+}
+
+// AddJobSet submits specs as a single coordinated group of heterogeneous
+// pods (e.g. a driver + N workers), mirroring the JobSet pattern: every
+// spec becomes its own batchv1.Job, all sharing the drmaa2jobset label, and
+// a headless Service is provisioned so members can reach each other by pod
+// DNS name. The returned ID identifies the whole set, not an individual Job.
+func (kt *KubernetesTracker) AddJobSet(specs []drmaa2interface.JobTemplate, policy JobSetPolicy) (string, error) {
+	if len(specs) == 0 {
+		return "", fmt.Errorf("AddJobSet: no job templates given")
+	}
+
+	for _, jt := range specs {
+		if err := kt.runValidators(jt); err != nil {
+			return "", fmt.Errorf("AddJobSet: validating job template: %s", err.Error())
+		}
+	}
+
+	guid, err := newJobSetGUID()
+	if err != nil {
+		return "", fmt.Errorf("AddJobSet: generating job set ID: %s", err.Error())
+	}
+
+	svcClient := kt.clientSet.CoreV1().Services(k8sDefaultNamespace)
+	svc := &corev1.Service{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: guid,
+			Labels: map[string]string{
+				"drmaa2jobsession": kt.jobsession,
+				drmaa2jobsetLabel:  guid,
+			},
+			Annotations: map[string]string{
+				"drmaa2jobset/failFast": fmt.Sprintf("%t", policy.FailFast),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector: map[string]string{
+				drmaa2jobsetLabel: guid,
+			},
+		},
+	}
+	if _, err := svcClient.Create(context.TODO(), svc, k8sapi.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("AddJobSet: creating headless service: %s", err.Error())
+	}
+
+	jc, err := getJobsClient(kt.clientSet)
+	if err != nil {
+		return "", fmt.Errorf("AddJobSet: %s", err.Error())
+	}
+
+	created := make([]string, 0, len(specs))
+	for _, jt := range specs {
+		job, err := convertJob(kt.jobsession, jt)
+		if err != nil {
+			kt.rollbackJobSet(guid, created)
+			return "", fmt.Errorf("AddJobSet: converting job template: %s", err.Error())
+		}
+		if job.Labels == nil {
+			job.Labels = map[string]string{}
+		}
+		job.Labels[drmaa2jobsetLabel] = guid
+		if job.Spec.Template.Labels == nil {
+			job.Spec.Template.Labels = map[string]string{}
+		}
+		job.Spec.Template.Labels[drmaa2jobsetLabel] = guid
+		job.Spec.Template.Spec.Subdomain = guid
+
+		if err := applyTTLExtension(job, jt); err != nil {
+			kt.rollbackJobSet(guid, created)
+			return "", fmt.Errorf("AddJobSet: applying %s extension: %s", K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED, err.Error())
+		}
+
+		if err := kt.runPodSpecMutators(job); err != nil {
+			kt.rollbackJobSet(guid, created)
+			return "", fmt.Errorf("AddJobSet: mutating member job: %s", err.Error())
+		}
+
+		j, err := jc.Create(context.TODO(), job, k8sapi.CreateOptions{})
+		if err != nil {
+			kt.rollbackJobSet(guid, created)
+			return "", fmt.Errorf("AddJobSet: creating member job: %s", err.Error())
+		}
+		created = append(created, j.Name)
+	}
+
+	return guid, nil
+}
+
+// rollbackJobSet removes already created member jobs and the headless
+// service when a later member in the set fails to submit.
+func (kt *KubernetesTracker) rollbackJobSet(guid string, members []string) {
+	jc, err := getJobsClient(kt.clientSet)
+	if err == nil {
+		for _, name := range members {
+			_ = jc.Delete(context.TODO(), name, k8sapi.DeleteOptions{})
+		}
+	}
+	_ = kt.clientSet.CoreV1().Services(k8sDefaultNamespace).Delete(context.TODO(), guid, k8sapi.DeleteOptions{})
+}
+
+// jobSetMembers lists the member Jobs of the job set identified by guid.
+func jobSetMembers(kt *KubernetesTracker, guid string) ([]batchv1.Job, error) {
+	jc, err := getJobsClient(kt.clientSet)
+	if err != nil {
+		return nil, err
+	}
+	labelSelector := fmt.Sprintf("%s=%s", drmaa2jobsetLabel, guid)
+	list, err := jc.List(context.TODO(), k8sapi.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// isJobSet reports whether id identifies a job set rather than an
+// individual Job or CronJob.
+func isJobSet(kt *KubernetesTracker, id string) (bool, error) {
+	members, err := jobSetMembers(kt, id)
+	if err != nil {
+		return false, err
+	}
+	return len(members) > 0, nil
+}
+
+// jobSetState aggregates the member jobs' states into a single
+// drmaa2interface.JobState: Failed if any member failed under FailFast (or,
+// without FailFast, once all members have finished and at least one
+// failed), Done only once every member succeeded, Running/Queued otherwise.
+func jobSetState(kt *KubernetesTracker, guid string) (drmaa2interface.JobState, error) {
+	members, err := jobSetMembers(kt, guid)
+	if err != nil {
+		return drmaa2interface.Undetermined, err
+	}
+	if len(members) == 0 {
+		return drmaa2interface.Undetermined, fmt.Errorf("job set %s not found", guid)
+	}
+
+	failFast, err := jobSetFailFast(kt, guid)
+	if err != nil {
+		return drmaa2interface.Undetermined, err
+	}
+
+	return aggregateJobSetState(members, failFast), nil
+}
+
+// aggregateJobSetState is the pure aggregation rule behind jobSetState:
+// Failed if any member failed under FailFast (or, without FailFast, once
+// all members have finished and at least one failed), Done only once every
+// member succeeded, Running/Queued otherwise. Split out from jobSetState so
+// it can be unit tested without a Kubernetes client.
+func aggregateJobSetState(members []batchv1.Job, failFast bool) drmaa2interface.JobState {
+	succeeded := 0
+	failed := 0
+	running := 0
+	for _, job := range members {
+		switch {
+		case job.Status.Succeeded > 0:
+			succeeded++
+		case job.Status.Failed > 0:
+			failed++
+			if failFast {
+				return drmaa2interface.Failed
+			}
+		case job.Status.Active > 0:
+			running++
+		}
+	}
+	if failed > 0 && succeeded+failed == len(members) {
+		return drmaa2interface.Failed
+	}
+	if succeeded == len(members) {
+		return drmaa2interface.Done
+	}
+	if running > 0 {
+		return drmaa2interface.Running
+	}
+	return drmaa2interface.Queued
+}
+
+// jobSetFailFast reports whether the job set's service (which carries the
+// set's metadata) was created with a FailFast policy.
+func jobSetFailFast(kt *KubernetesTracker, guid string) (bool, error) {
+	svc, err := kt.clientSet.CoreV1().Services(k8sDefaultNamespace).Get(context.TODO(), guid, k8sapi.GetOptions{})
+	if err != nil {
+		return false, nil
+	}
+	return svc.Annotations["drmaa2jobset/failFast"] == "true", nil
+}
+
+// jobSetJobInfo returns a minimal aggregated JobInfo for the job set.
+func jobSetJobInfo(kt *KubernetesTracker, guid string) (drmaa2interface.JobInfo, error) {
+	state, err := jobSetState(kt, guid)
+	if err != nil {
+		return drmaa2interface.JobInfo{}, err
+	}
+	return drmaa2interface.JobInfo{
+		ID:    guid,
+		State: state,
+	}, nil
+}
+
+// jobSetControl applies a suspend/resume/hold/release/terminate action to
+// every member of the job set identified by guid, treating the set as one
+// entity like jobSetState and deleteJobSet do. terminate cascades to
+// deleteJobSet rather than calling jobStateChange per member.
+func jobSetControl(kt *KubernetesTracker, guid, state string) error {
+	if state == "terminate" {
+		return deleteJobSet(kt, guid)
+	}
+
+	jc, err := getJobsClient(kt.clientSet)
+	if err != nil {
+		return fmt.Errorf("JobControl: job set %s: %s", guid, err.Error())
+	}
+	members, err := jobSetMembers(kt, guid)
+	if err != nil {
+		return fmt.Errorf("JobControl: job set %s: %s", guid, err.Error())
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("job set %s not found", guid)
+	}
+	for i := range members {
+		if err := jobStateChange(jc, &members[i], state); err != nil {
+			return fmt.Errorf("JobControl: job set %s member %s: %s", guid, members[i].Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// deleteJobSet cascades deletion to every member Job and the headless
+// Service backing guid.
+func deleteJobSet(kt *KubernetesTracker, guid string) error {
+	members, err := jobSetMembers(kt, guid)
+	if err != nil {
+		return fmt.Errorf("deleting job set %s: %s", guid, err.Error())
+	}
+	jc, err := getJobsClient(kt.clientSet)
+	if err != nil {
+		return fmt.Errorf("deleting job set %s: %s", guid, err.Error())
+	}
+	propagation := k8sapi.DeletePropagationForeground
+	for _, job := range members {
+		if err := jc.Delete(context.TODO(), job.Name, k8sapi.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return fmt.Errorf("deleting job set member %s: %s", job.Name, err.Error())
+		}
+	}
+	if err := kt.clientSet.CoreV1().Services(k8sDefaultNamespace).Delete(context.TODO(), guid, k8sapi.DeleteOptions{}); err != nil {
+		return fmt.Errorf("deleting job set service %s: %s", guid, err.Error())
+	}
+	return nil
+}
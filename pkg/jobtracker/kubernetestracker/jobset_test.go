@@ -0,0 +1,68 @@
+package kubernetestracker
+
+import (
+	"testing"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+func TestAggregateJobSetState(t *testing.T) {
+	testCases := []struct {
+		name     string
+		members  []batchv1.Job
+		failFast bool
+		want     drmaa2interface.JobState
+	}{
+		{
+			name: "all succeeded is Done",
+			members: []batchv1.Job{
+				{Status: batchv1.JobStatus{Succeeded: 1}},
+				{Status: batchv1.JobStatus{Succeeded: 1}},
+			},
+			want: drmaa2interface.Done,
+		},
+		{
+			name: "one failure under FailFast is immediately Failed",
+			members: []batchv1.Job{
+				{Status: batchv1.JobStatus{Failed: 1}},
+				{Status: batchv1.JobStatus{Active: 1}},
+			},
+			failFast: true,
+			want:     drmaa2interface.Failed,
+		},
+		{
+			name: "one failure without FailFast stays Running until all finish",
+			members: []batchv1.Job{
+				{Status: batchv1.JobStatus{Failed: 1}},
+				{Status: batchv1.JobStatus{Active: 1}},
+			},
+			failFast: false,
+			want:     drmaa2interface.Running,
+		},
+		{
+			name: "failure without FailFast is Failed once every member finished",
+			members: []batchv1.Job{
+				{Status: batchv1.JobStatus{Failed: 1}},
+				{Status: batchv1.JobStatus{Succeeded: 1}},
+			},
+			failFast: false,
+			want:     drmaa2interface.Failed,
+		},
+		{
+			name: "no active status yet is Queued",
+			members: []batchv1.Job{
+				{},
+			},
+			want: drmaa2interface.Queued,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := aggregateJobSetState(tc.members, tc.failFast); got != tc.want {
+				t.Errorf("aggregateJobSetState() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -2,15 +2,14 @@ package kubernetestracker
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"time"
 
 	"github.com/dgruber/drmaa2interface"
 	"github.com/dgruber/drmaa2os"
 	"github.com/dgruber/drmaa2os/pkg/helper"
-	"github.com/dgruber/drmaa2os/pkg/jobtracker"
 	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -18,8 +17,27 @@ const K8S_JT_EXTENSION_NAMESPACE = "namespace"
 const K8S_JT_EXTENSION_LABELS = "labels"
 
 type KubernetesTracker struct {
-	clientSet  *kubernetes.Clientset
-	jobsession string
+	clientSet       *kubernetes.Clientset
+	jobsession      string
+	reaper          *ttlReaper
+	drivers         map[string]WorkloadKindDriver
+	dynClient       dynamic.Interface
+	validators      []JobTemplateValidator
+	podSpecMutators []PodSpecMutator
+}
+
+// dynamicClient lazily builds (and caches) the dynamic client used to
+// submit and list non-batchv1.Job workload kinds.
+func (kt *KubernetesTracker) dynamicClient() (dynamic.Interface, error) {
+	if kt.dynClient != nil {
+		return kt.dynClient, nil
+	}
+	dc, err := NewDynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	kt.dynClient = dc
+	return dc, nil
 }
 
 // init registers the Kubernetes job tracker at the SessionManager
@@ -27,30 +45,15 @@ func init() {
 	drmaa2os.RegisterJobTracker(drmaa2os.KubernetesSession, NewAllocator())
 }
 
-type allocator struct{}
-
-func NewAllocator() *allocator {
-	return &allocator{}
-}
-
-// New is called by the SessionManager when a new JobSession is allocated.
-// jobTrackerInitParams must be a kubernetes.Clientset if not nil. If nil
-// a new Clientset is allocated.
-func (a *allocator) New(jobSessionName string, jobTrackerInitParams interface{}) (jobtracker.JobTracker, error) {
-	var cs *kubernetes.Clientset
-	//
-	if jobTrackerInitParams != nil {
-		var ok bool
-		cs, ok = jobTrackerInitParams.(*kubernetes.Clientset)
-		if !ok {
-			return nil, errors.New("jobTrackerInitParams is not of type *kubernetes.Clientset")
-		}
-	}
-	return New(jobSessionName, cs)
-}
-
 // New creates a new KubernetesTracker either by using a given kubernetes Clientset
-// or by allocating a new one (if the parameter is zero).
+// or by allocating a new one (if the parameter is zero). batchv1.Job,
+// CronJob and JobSet submissions always go through the tracker's built-in
+// handling (convertJob, cronjob.go, jobset.go) rather than the
+// WorkloadKindDriver abstraction; use an allocator created with
+// NewAllocator to additionally submit other workload kinds (TFJob,
+// PyTorchJob, MPIJob, Volcano Job, ...) through their own
+// WorkloadKindDriver, keyed by a JobTemplate's K8S_JT_EXTENSION_WORKLOAD_KIND
+// extension.
 func New(jobsession string, cs *kubernetes.Clientset) (*KubernetesTracker, error) {
 	if cs == nil {
 		var err error
@@ -59,10 +62,48 @@ func New(jobsession string, cs *kubernetes.Clientset) (*KubernetesTracker, error
 			return nil, err
 		}
 	}
-	return &KubernetesTracker{
+	kt := &KubernetesTracker{
 		clientSet:  cs,
 		jobsession: jobsession,
-	}, nil
+	}
+	kt.drivers = map[string]WorkloadKindDriver{}
+	kt.validators = append([]JobTemplateValidator{}, builtinValidators...)
+	return kt, nil
+}
+
+// Options configures optional behavior of a KubernetesTracker created
+// through NewWithOptions.
+type Options struct {
+	// EnableTTLReaper starts an in-process goroutine which deletes jobs
+	// once their ttlSecondsAfterFinished extension elapses, for clusters
+	// whose own TTL controller is unavailable (e.g. older clusters).
+	EnableTTLReaper bool
+}
+
+// NewWithOptions creates a new KubernetesTracker like New but additionally
+// allows enabling the in-process TTL reaper through opts.
+func NewWithOptions(jobsession string, cs *kubernetes.Clientset, opts Options) (*KubernetesTracker, error) {
+	kt, err := New(jobsession, cs)
+	if err != nil {
+		return nil, err
+	}
+	if opts.EnableTTLReaper {
+		kt.reaper = newTTLReaper(kt.clientSet, kt.jobsession)
+		kt.reaper.Start()
+	}
+	return kt, nil
+}
+
+// Close releases resources held by the tracker, in particular stopping the
+// in-process TTL reaper started through NewWithOptions (if any). Callers
+// that enable the reaper are responsible for calling Close once the
+// JobSession is no longer needed, to avoid leaking its watch goroutine.
+func (kt *KubernetesTracker) Close() error {
+	if kt.reaper != nil {
+		kt.reaper.Stop()
+		kt.reaper = nil
+	}
+	return nil
 }
 
 func (kt *KubernetesTracker) ListJobCategories() ([]string, error) {
@@ -70,7 +111,8 @@ func (kt *KubernetesTracker) ListJobCategories() ([]string, error) {
 }
 
 // ListJobs returns a list of job IDs associated with the current
-// DRMAA2 job session.
+// DRMAA2 job session. Both one-shot Jobs and recurring CronJobs created
+// by AddJob are included.
 func (kt *KubernetesTracker) ListJobs() ([]string, error) {
 	jc, err := getJobsClient(kt.clientSet)
 	if err != nil {
@@ -82,19 +124,89 @@ func (kt *KubernetesTracker) ListJobs() ([]string, error) {
 		return nil, fmt.Errorf("listing jobs with client: %s", err.Error())
 	}
 	ids := make([]string, 0, len(jobsList.Items))
+	jobsets := make(map[string]bool)
 	for _, job := range jobsList.Items {
+		if guid, ok := job.Labels[drmaa2jobsetLabel]; ok {
+			jobsets[guid] = true
+			continue
+		}
 		ids = append(ids, string(job.Name))
 	}
+	for guid := range jobsets {
+		ids = append(ids, guid)
+	}
+
+	cjc, err := getCronJobsClient(kt)
+	if err != nil {
+		return nil, fmt.Errorf("ListJobs: %s", err.Error())
+	}
+	cronJobsList, err := cjc.List(context.TODO(), k8sapi.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("listing cron jobs with client: %s", err.Error())
+	}
+	for _, cronJob := range cronJobsList.Items {
+		ids = append(ids, string(cronJob.Name))
+	}
+
+	for kind, driver := range kt.drivers {
+		if kind == defaultWorkloadKind {
+			continue
+		}
+		driverIDs, err := kt.listDriverResources(driver)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s resources: %s", kind, err.Error())
+		}
+		ids = append(ids, driverIDs...)
+	}
+	return ids, nil
+}
+
+// listDriverResources lists the names of resources of driver's kind
+// belonging to the current job session, through the generic dynamic client.
+func (kt *KubernetesTracker) listDriverResources(driver WorkloadKindDriver) ([]string, error) {
+	dc, err := kt.dynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	gvr := gvrForKind(driver.GVK())
+	list, err := dc.Resource(gvr).Namespace(k8sDefaultNamespace).List(context.TODO(), k8sapi.ListOptions{
+		LabelSelector: fmt.Sprintf("drmaa2jobsession=%s", kt.jobsession),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		ids = append(ids, item.GetName())
+	}
 	return ids, nil
 }
 
 // AddJob converts the given DRMAA2 job template into a batchv1.Job and creates
-// the job within Kubernetes.
+// the job within Kubernetes. When jt carries the K8S_JT_EXTENSION_CRON_SCHEDULE
+// extension a recurring batchv1.CronJob is created instead, and when it
+// carries K8S_JT_EXTENSION_WORKLOAD_KIND it is submitted through the
+// matching WorkloadKindDriver instead (e.g. as a Kubeflow TFJob).
 func (kt *KubernetesTracker) AddJob(jt drmaa2interface.JobTemplate) (string, error) {
+	if err := kt.runValidators(jt); err != nil {
+		return "", fmt.Errorf("validating job template: %s", err.Error())
+	}
+	if kind, exists := jt.ExtensionList[K8S_JT_EXTENSION_WORKLOAD_KIND]; exists && kind != defaultWorkloadKind {
+		return kt.addViaDriver(kind, jt)
+	}
+	if isCronJobTemplate(jt) {
+		return kt.addCronJob(jt)
+	}
 	job, err := convertJob(kt.jobsession, jt)
 	if err != nil {
 		return "", fmt.Errorf("converting job template into a k8s job: %s", err.Error())
 	}
+	if err := applyTTLExtension(job, jt); err != nil {
+		return "", fmt.Errorf("applying %s extension: %s", K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED, err.Error())
+	}
+	if err := kt.runPodSpecMutators(job); err != nil {
+		return "", fmt.Errorf("mutating job: %s", err.Error())
+	}
 	jc, err := getJobsClient(kt.clientSet)
 	if err != nil {
 		return "", fmt.Errorf("get client: %s", err.Error())
@@ -115,24 +227,75 @@ func (kt *KubernetesTracker) ListArrayJobs(id string) ([]string, error) {
 }
 
 func (kt *KubernetesTracker) JobState(jobid string) (drmaa2interface.JobState, string, error) {
-	jc, err := getJobsClient(kt.clientSet)
-	if err != nil {
+	owner := kt.resolveOwner(jobid)
+	switch owner.kind {
+	case ownerJobSet:
+		state, err := jobSetState(kt, jobid)
+		if err != nil {
+			return drmaa2interface.Undetermined, "", nil
+		}
+		return state, "", nil
+	case ownerDriver:
+		return owner.driver.State(jobid), "", nil
+	case drmaa2kindCronJob:
+		cjc, err := getCronJobsClient(kt)
+		if err != nil {
+			return drmaa2interface.Undetermined, "", nil
+		}
+		return cronJobState(cjc, jobid), "", nil
+	case drmaa2kindJob:
+		jc, err := getJobsClient(kt.clientSet)
+		if err != nil {
+			return drmaa2interface.Undetermined, "", nil
+		}
+		return DRMAA2State(jc, jobid), "", nil
+	default:
 		return drmaa2interface.Undetermined, "", nil
 	}
-	return DRMAA2State(jc, jobid), "", nil
 }
 
 func (kt *KubernetesTracker) JobInfo(jobid string) (drmaa2interface.JobInfo, error) {
-	jc, err := getJobsClient(kt.clientSet)
-	if err != nil {
-		return drmaa2interface.JobInfo{}, err
+	owner := kt.resolveOwner(jobid)
+	switch owner.kind {
+	case ownerJobSet:
+		return jobSetJobInfo(kt, jobid)
+	case ownerDriver:
+		return drmaa2interface.JobInfo{ID: jobid, State: owner.driver.State(jobid)}, nil
+	case drmaa2kindCronJob:
+		cjc, err := getCronJobsClient(kt)
+		if err != nil {
+			return drmaa2interface.JobInfo{}, err
+		}
+		return CronJobToJobInfo(cjc, jobid)
+	case drmaa2kindJob:
+		jc, err := getJobsClient(kt.clientSet)
+		if err != nil {
+			return drmaa2interface.JobInfo{}, err
+		}
+		return JobToJobInfo(jc, jobid)
+	default:
+		return drmaa2interface.JobInfo{}, fmt.Errorf("job %s not found", jobid)
 	}
-	return JobToJobInfo(jc, jobid)
 }
 
 // JobControl changes the state of the given job by execution the given action
-// (suspend, resume, hold, release, terminate).
+// (suspend, resume, hold, release, terminate). For a CronJob, suspend and
+// resume flip spec.suspend instead of erroring out.
 func (kt *KubernetesTracker) JobControl(jobid, state string) error {
+	if isSet, err := isJobSet(kt, jobid); err == nil && isSet {
+		return jobSetControl(kt, jobid, state)
+	}
+	kind, err := resolveJobKind(kt, jobid)
+	if err != nil {
+		return fmt.Errorf("JobControl: %s", err.Error())
+	}
+	if kind == drmaa2kindCronJob {
+		cjc, err := getCronJobsClient(kt)
+		if err != nil {
+			return fmt.Errorf("JobControl: %s", err.Error())
+		}
+		return cronJobControl(cjc, jobid, state)
+	}
 	jc, job, err := getJobInterfaceAndJob(kt.clientSet, jobid)
 	if err != nil {
 		return fmt.Errorf("JobControl: %s", err.Error())
@@ -141,16 +304,79 @@ func (kt *KubernetesTracker) JobControl(jobid, state string) error {
 }
 
 // Wait returns when the job is in one of the given states or when a timeout
-// occurs (errors then).
+// occurs (errors then). For a plain batchv1.Job this watches the job (and,
+// while Pending/Running, its pods) instead of polling. CronJobs and job sets
+// have no single terminal Kubernetes watch to follow and fall back to
+// helper.WaitForState's polling.
 func (kt *KubernetesTracker) Wait(jobid string, timeout time.Duration, states ...drmaa2interface.JobState) error {
-	return helper.WaitForState(kt, jobid, timeout, states...)
+	if isSet, err := isJobSet(kt, jobid); err == nil && isSet {
+		return helper.WaitForState(kt, jobid, timeout, states...)
+	}
+	if kind, err := resolveJobKind(kt, jobid); err != nil || kind != drmaa2kindJob {
+		return helper.WaitForState(kt, jobid, timeout, states...)
+	}
+	return kt.watchWaitForJob(jobid, timeout, states)
 }
 
-// DeleteJob removes a job from kubernetes.
+// DeleteJob removes a job (or cron job, or job set) from kubernetes.
 func (kt *KubernetesTracker) DeleteJob(jobid string) error {
-	jc, job, err := getJobInterfaceAndJob(kt.clientSet, jobid)
-	if err != nil {
-		return fmt.Errorf("DeleteJob: %s", err.Error())
+	owner := kt.resolveOwner(jobid)
+	switch owner.kind {
+	case ownerJobSet:
+		return deleteJobSet(kt, jobid)
+	case ownerDriver:
+		return owner.driver.Delete(jobid)
+	case drmaa2kindCronJob:
+		cjc, err := getCronJobsClient(kt)
+		if err != nil {
+			return fmt.Errorf("DeleteJob: %s", err.Error())
+		}
+		return deleteCronJob(cjc, jobid)
+	case drmaa2kindJob:
+		jc, job, err := getJobInterfaceAndJob(kt.clientSet, jobid)
+		if err != nil {
+			return fmt.Errorf("DeleteJob: %s", err.Error())
+		}
+		return deleteJob(jc, job)
+	default:
+		return fmt.Errorf("DeleteJob: job %s not found", jobid)
+	}
+}
+
+// ownerKind classifies what a DRMAA2 job ID resolves to, as determined by
+// resolveOwner.
+type ownerKind string
+
+const (
+	ownerJobSet ownerKind = "jobset"
+	ownerDriver ownerKind = "driver"
+)
+
+// owner is the result of resolveOwner: either kind is drmaa2kindJob or
+// drmaa2kindCronJob (plain Job/CronJob, resolved via resolveJobKind), or
+// kind is ownerJobSet, or kind is ownerDriver with driver set, or kind is
+// empty if jobid could not be resolved to anything.
+type owner struct {
+	kind   ownerKind
+	driver WorkloadKindDriver
+}
+
+// resolveOwner classifies jobid, trying the cheapest path first:
+// resolveJobKind's Job Get, falling back to a CronJob Get only if that
+// misses, covers the overwhelming majority of lookups in one or two API
+// calls. Only once that fails does it fall back to the job set membership
+// List and, as a last resort, probing every registered WorkloadKindDriver -
+// JobState, JobInfo and DeleteJob used to always pay for all three paths on
+// every call regardless of what jobid actually was.
+func (kt *KubernetesTracker) resolveOwner(jobid string) owner {
+	if kind, err := resolveJobKind(kt, jobid); err == nil {
+		return owner{kind: ownerKind(kind)}
+	}
+	if isSet, err := isJobSet(kt, jobid); err == nil && isSet {
+		return owner{kind: ownerJobSet}
+	}
+	if driver, ok := kt.driverOwning(jobid); ok {
+		return owner{kind: ownerDriver, driver: driver}
 	}
-	return deleteJob(jc, job)
+	return owner{}
 }
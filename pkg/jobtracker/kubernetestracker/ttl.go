@@ -0,0 +1,181 @@
+package kubernetestracker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED maps onto
+// batchv1.JobSpec.TTLSecondsAfterFinished. "0" is a valid value meaning
+// "delete immediately after completion" and is distinct from the extension
+// being unset (leave cleanup to the caller / cluster default).
+const K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED = "ttlSecondsAfterFinished"
+
+// applyTTLExtension sets job.Spec.TTLSecondsAfterFinished from the
+// K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED extension, if present.
+func applyTTLExtension(job *batchv1.Job, jt drmaa2interface.JobTemplate) error {
+	v, exists := jt.ExtensionList[K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED]
+	if !exists {
+		return nil
+	}
+	seconds, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return err
+	}
+	ttl := int32(seconds)
+	job.Spec.TTLSecondsAfterFinished = &ttl
+	return nil
+}
+
+// ttlReaper enforces a Job's ttlSecondsAfterFinished extension by deleting
+// completed jobs in-process, for clusters whose own TTL-after-finished
+// controller is unavailable (e.g. older clusters without the feature gate).
+type ttlReaper struct {
+	clientSet  *kubernetes.Clientset
+	jobsession string
+
+	stopCh  chan struct{}
+	deleted sync.Map // job name -> struct{}, prevents double-delete
+}
+
+func newTTLReaper(cs *kubernetes.Clientset, jobsession string) *ttlReaper {
+	return &ttlReaper{
+		clientSet:  cs,
+		jobsession: jobsession,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start runs the reaper's watch loop in a new goroutine.
+func (r *ttlReaper) Start() {
+	go r.run()
+}
+
+// Stop terminates the reaper's watch loop.
+func (r *ttlReaper) Stop() {
+	close(r.stopCh)
+}
+
+// run re-establishes the watch whenever it ends: immediately after a 410
+// Gone resourceVersion expiry or a closed result channel, and with
+// exponential backoff when List or Watch fails or the watch reports any
+// other error.
+func (r *ttlReaper) run() {
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		default:
+		}
+		if err := r.watchOnce(); err != nil {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// watchOnce opens a single watch on jobs belonging to the reaper's job
+// session and enforces TTLs on completion events until the watch ends.
+func (r *ttlReaper) watchOnce() error {
+	jc := r.clientSet.BatchV1().Jobs(k8sDefaultNamespace)
+	labelSelector := "drmaa2jobsession=" + r.jobsession
+
+	list, err := jc.List(context.TODO(), k8sapi.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return err
+	}
+	for _, job := range list.Items {
+		r.reapIfFinished(&job)
+	}
+
+	w, err := jc.Watch(context.TODO(), k8sapi.ListOptions{
+		LabelSelector:   labelSelector,
+		ResourceVersion: list.ResourceVersion,
+		FieldSelector:   fields.Everything().String(),
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-r.stopCh:
+			return nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			if event.Type == watch.Error {
+				if status, ok := event.Object.(*k8sapi.Status); ok && apierrors.IsResourceExpired(&apierrors.StatusError{ErrStatus: *status}) {
+					return nil
+				}
+				return apierrors.FromObject(event.Object)
+			}
+			job, ok := event.Object.(*batchv1.Job)
+			if !ok {
+				continue
+			}
+			r.reapIfFinished(job)
+		}
+	}
+}
+
+// reapIfFinished deletes job if it has finished and its TTL (including a
+// TTL of 0, meaning "immediately") has elapsed.
+func (r *ttlReaper) reapIfFinished(job *batchv1.Job) {
+	if job.Spec.TTLSecondsAfterFinished == nil {
+		return
+	}
+	// CompletionTime is only set on success; a failed job finishes at its
+	// JobFailed condition's transition time.
+	finishedAt := job.Status.CompletionTime
+	if finishedAt == nil {
+		for _, c := range job.Status.Conditions {
+			if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+				t := c.LastTransitionTime
+				finishedAt = &t
+				break
+			}
+		}
+	}
+	if finishedAt == nil {
+		return
+	}
+	if _, alreadyDeleted := r.deleted.LoadOrStore(job.Name, struct{}{}); alreadyDeleted {
+		return
+	}
+
+	ttl := time.Duration(*job.Spec.TTLSecondsAfterFinished) * time.Second
+	elapsed := time.Since(finishedAt.Time)
+	wait := ttl - elapsed
+	if wait < 0 {
+		wait = 0
+	}
+
+	name := job.Name
+	time.AfterFunc(wait, func() {
+		propagation := k8sapi.DeletePropagationForeground
+		_ = r.clientSet.BatchV1().Jobs(k8sDefaultNamespace).Delete(context.TODO(), name, k8sapi.DeleteOptions{
+			PropagationPolicy: &propagation,
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package kubernetestracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyTTLExtensionUnset(t *testing.T) {
+	job := &batchv1.Job{}
+	jt := drmaa2interface.JobTemplate{}
+	if err := applyTTLExtension(job, jt); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if job.Spec.TTLSecondsAfterFinished != nil {
+		t.Fatal("TTLSecondsAfterFinished should stay nil when the extension is not set")
+	}
+}
+
+func TestApplyTTLExtensionZeroIsDistinctFromUnset(t *testing.T) {
+	job := &batchv1.Job{}
+	jt := drmaa2interface.JobTemplate{
+		ExtensionList: map[string]string{K8S_JT_EXTENSION_TTL_SECONDS_AFTER_FINISHED: "0"},
+	}
+	if err := applyTTLExtension(job, jt); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil {
+		t.Fatal("TTL of \"0\" must set an explicit pointer to 0, not leave it unset")
+	}
+	if *job.Spec.TTLSecondsAfterFinished != 0 {
+		t.Fatalf("TTLSecondsAfterFinished = %d, want 0", *job.Spec.TTLSecondsAfterFinished)
+	}
+}
+
+func TestReapIfFinishedDoesNotDoubleDelete(t *testing.T) {
+	// A deliberately huge TTL keeps the scheduled deletion (time.AfterFunc)
+	// from actually firing during the test, so a nil clientSet is fine -
+	// only the LoadOrStore dedup guard, which runs synchronously, is
+	// exercised here.
+	r := newTTLReaper(nil, "session1")
+
+	ttl := int32(9999)
+	job := &batchv1.Job{
+		ObjectMeta: k8sapi.ObjectMeta{Name: "job1"},
+		Status: batchv1.JobStatus{
+			CompletionTime: &k8sapi.Time{Time: time.Now()},
+		},
+		Spec: batchv1.JobSpec{TTLSecondsAfterFinished: &ttl},
+	}
+
+	r.reapIfFinished(job)
+	r.reapIfFinished(job)
+
+	if _, ok := r.deleted.Load("job1"); !ok {
+		t.Fatal("job1 should be tracked as deleted after reapIfFinished")
+	}
+	count := 0
+	r.deleted.Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Fatalf("expected exactly one tracked deletion, got %d", count)
+	}
+}
+
+func TestReapIfFinishedSkipsUnfinishedJobs(t *testing.T) {
+	r := newTTLReaper(nil, "session1")
+
+	ttl := int32(30)
+	job := &batchv1.Job{
+		ObjectMeta: k8sapi.ObjectMeta{Name: "job2"},
+		Spec:       batchv1.JobSpec{TTLSecondsAfterFinished: &ttl},
+	}
+
+	r.reapIfFinished(job)
+
+	if _, ok := r.deleted.Load("job2"); ok {
+		t.Fatal("a job without a CompletionTime must not be scheduled for deletion")
+	}
+}
@@ -0,0 +1,109 @@
+package kubernetestracker
+
+import (
+	"fmt"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// K8S_JT_EXTENSION_REQUIRE_RESOURCE_REQUESTS, when set to "true" on a
+// JobTemplate, makes the built-in validator set reject the template if its
+// MinPhysMemory (memory request) is unset. CPU requests and the individual
+// containers of the generated pod are not checked.
+const K8S_JT_EXTENSION_REQUIRE_RESOURCE_REQUESTS = "requireResourceRequests"
+
+// JobTemplateValidator inspects a JobTemplate before it is converted into
+// Kubernetes objects and returns an error to reject it. Validators run in
+// registration order, built-ins first.
+type JobTemplateValidator func(drmaa2interface.JobTemplate) error
+
+// PodSpecMutator amends the batchv1.Job generated from a JobTemplate (e.g.
+// to inject sidecars, a service account, a security context or a node
+// selector) before it is submitted to the API server. Mutators run in
+// registration order, after all validators passed.
+type PodSpecMutator func(*batchv1.Job) error
+
+// RegisterValidator adds v to the validation pipeline this allocator's
+// trackers run in AddJob (and AddJobSet) before a JobTemplate is converted
+// into Kubernetes objects. Like RegisterPodSpecMutator and the
+// WorkloadKindDriver registry (chunk0-6), registration is scoped to this
+// allocator - and therefore to the JobSessions it creates - not global to
+// the process.
+func (a *allocator) RegisterValidator(v JobTemplateValidator) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.validators = append(a.validators, v)
+}
+
+// RegisterPodSpecMutator adds m to the pipeline run on the generated
+// batchv1.Job after validation and conversion, before it is created.
+func (a *allocator) RegisterPodSpecMutator(m PodSpecMutator) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.podSpecMutators = append(a.podSpecMutators, m)
+}
+
+// runValidators executes every validator registered for kt (built-ins
+// first, then any this tracker's allocator added) against jt, returning the
+// first error encountered.
+func (kt *KubernetesTracker) runValidators(jt drmaa2interface.JobTemplate) error {
+	for _, v := range kt.validators {
+		if err := v(jt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPodSpecMutators applies every mutator registered for kt to job, in
+// registration order.
+func (kt *KubernetesTracker) runPodSpecMutators(job *batchv1.Job) error {
+	for _, m := range kt.podSpecMutators {
+		if err := m(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// builtinValidators cover common misconfigurations integrators otherwise
+// only discover once a pod is already scheduled.
+var builtinValidators = []JobTemplateValidator{
+	validateImageSet,
+	validateNamespace,
+	validateResourceRequests,
+}
+
+func validateImageSet(jt drmaa2interface.JobTemplate) error {
+	if jt.JobCategory == "" && jt.RemoteCommand == "" {
+		return fmt.Errorf("validation: neither JobCategory (container image) nor RemoteCommand is set")
+	}
+	return nil
+}
+
+func validateNamespace(jt drmaa2interface.JobTemplate) error {
+	ns, exists := jt.ExtensionList[K8S_JT_EXTENSION_NAMESPACE]
+	if !exists {
+		return nil
+	}
+	if ns == "" {
+		return fmt.Errorf("validation: %s extension must not be empty", K8S_JT_EXTENSION_NAMESPACE)
+	}
+	for _, r := range ns {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+			return fmt.Errorf("validation: %s %q is not a valid Kubernetes namespace name", K8S_JT_EXTENSION_NAMESPACE, ns)
+		}
+	}
+	return nil
+}
+
+func validateResourceRequests(jt drmaa2interface.JobTemplate) error {
+	if jt.ExtensionList[K8S_JT_EXTENSION_REQUIRE_RESOURCE_REQUESTS] != "true" {
+		return nil
+	}
+	if jt.MinPhysMemory == 0 {
+		return fmt.Errorf("validation: %s is set but MinPhysMemory (resource request) is unset", K8S_JT_EXTENSION_REQUIRE_RESOURCE_REQUESTS)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package kubernetestracker
+
+import (
+	"testing"
+
+	"github.com/dgruber/drmaa2interface"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestRegisterValidatorIsScopedPerAllocator(t *testing.T) {
+	cs := &kubernetes.Clientset{}
+	jt := drmaa2interface.JobTemplate{JobCategory: "busybox"}
+	called := false
+	recording := func(drmaa2interface.JobTemplate) error {
+		called = true
+		return nil
+	}
+
+	a := NewAllocator()
+	a.RegisterValidator(recording)
+
+	tracked, err := a.New("session1", cs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	kt := tracked.(*KubernetesTracker)
+	if err := kt.runValidators(jt); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !called {
+		t.Fatal("validator registered on the allocator should run for trackers it creates")
+	}
+
+	called = false
+	plain, err := New("session2", cs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err := plain.runValidators(jt); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if called {
+		t.Fatal("a validator registered on one allocator must not leak into trackers created through plain New")
+	}
+
+	other := NewAllocator()
+	otherTracked, err := other.New("session3", cs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	called = false
+	if err := otherTracked.(*KubernetesTracker).runValidators(jt); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if called {
+		t.Fatal("a validator registered on one allocator must not leak into a different allocator's trackers")
+	}
+}
@@ -0,0 +1,203 @@
+package kubernetestracker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// watchWaitForJob blocks until jobid reaches one of wanted, or timeout
+// elapses, by watching the Job object (and, while it is Pending or
+// Running, its pods for richer transition reporting) instead of polling
+// JobState in a loop.
+func (kt *KubernetesTracker) watchWaitForJob(jobid string, timeout time.Duration, wanted []drmaa2interface.JobState) error {
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	jc := kt.clientSet.BatchV1().Jobs(k8sDefaultNamespace)
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", jobid).String()
+
+	// timeout == 0 is the conventional "check current state once, don't
+	// block" sentinel: do a single immediate Get without a watch.
+	singleShot := timeout == 0
+
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		job, err := jc.Get(ctx, jobid, k8sapi.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("Wait: getting job %s: %s", jobid, err.Error())
+		}
+		if state := jobObjectState(job); wantsState(wanted, state) {
+			return nil
+		}
+		if singleShot {
+			return fmt.Errorf("Wait: job %s is not yet in one of the requested states", jobid)
+		}
+
+		w, err := jc.Watch(ctx, k8sapi.ListOptions{
+			FieldSelector:   fieldSelector,
+			ResourceVersion: job.ResourceVersion,
+		})
+		if err != nil {
+			return fmt.Errorf("Wait: watching job %s: %s", jobid, err.Error())
+		}
+
+		var podWatch watch.Interface
+		if state := jobObjectState(job); state == drmaa2interface.Queued || state == drmaa2interface.Running {
+			podWatch, err = watchPodsForJob(ctx, kt, jobid)
+			if err != nil {
+				podWatch = nil
+			}
+		}
+
+		done, reached, needsBackoff, err := waitOnJobEvents(ctx, w, podWatch, wanted)
+		w.Stop()
+		if podWatch != nil {
+			podWatch.Stop()
+		}
+		if err != nil {
+			return err
+		}
+		if done {
+			if reached {
+				return nil
+			}
+			if wantsState(wanted, drmaa2interface.Undetermined) {
+				return nil
+			}
+			return fmt.Errorf("Wait: job %s was deleted before reaching one of the requested states", jobid)
+		}
+		if needsBackoff {
+			// A persistent watch error (bad RBAC, API server hiccup, ...)
+			// - back off instead of hot-looping Get+Watch, mirroring the
+			// TTL reaper's reconnect behavior.
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("Wait: timeout waiting for job state: %s", ctx.Err().Error())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+		// ResourceVersion expired (410 Gone) or the channel closed for
+		// another reason - re-establish with a fresh Get and Watch.
+	}
+}
+
+// waitOnJobEvents consumes events from the job watch w, and (if non-nil) the
+// follow-on pod watch podWatch, until one of wanted is observed (done=true,
+// reached=true), the job is deleted (done=true, reached=false - the caller
+// decides whether Undetermined was requested, resolving to Undetermined
+// rather than hanging), the context expires (err set), or the watch needs
+// to be re-established (done=false, needsBackoff set for a watch.Error other
+// than an expired resourceVersion so the caller backs off before
+// reconnecting).
+func waitOnJobEvents(ctx context.Context, w watch.Interface, podWatch watch.Interface, wanted []drmaa2interface.JobState) (done bool, reached bool, needsBackoff bool, err error) {
+	var podEvents <-chan watch.Event
+	if podWatch != nil {
+		podEvents = podWatch.ResultChan()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return true, false, false, fmt.Errorf("Wait: timeout waiting for job state: %s", ctx.Err().Error())
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, false, false, nil
+			}
+			switch event.Type {
+			case watch.Error:
+				if status, ok := event.Object.(*k8sapi.Status); ok && apierrors.IsResourceExpired(&apierrors.StatusError{ErrStatus: *status}) {
+					return false, false, false, nil
+				}
+				return false, false, true, nil
+			case watch.Deleted:
+				return true, false, false, nil
+			case watch.Added, watch.Modified:
+				job, ok := event.Object.(*batchv1.Job)
+				if !ok {
+					continue
+				}
+				if state := jobObjectState(job); wantsState(wanted, state) {
+					return true, true, false, nil
+				}
+			}
+		case event, ok := <-podEvents:
+			if !ok {
+				podEvents = nil
+				continue
+			}
+			pod, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			if state := podObjectState(pod); wantsState(wanted, state) {
+				return true, true, false, nil
+			}
+		}
+	}
+}
+
+// jobObjectState maps a batchv1.Job onto a drmaa2interface.JobState without
+// requiring a further API call, mirroring DRMAA2State's mapping.
+func jobObjectState(job *batchv1.Job) drmaa2interface.JobState {
+	switch {
+	case job.Status.Succeeded > 0:
+		return drmaa2interface.Done
+	case job.Status.Failed > 0:
+		return drmaa2interface.Failed
+	case job.Status.Active > 0:
+		return drmaa2interface.Running
+	default:
+		return drmaa2interface.Queued
+	}
+}
+
+func wantsState(wanted []drmaa2interface.JobState, state drmaa2interface.JobState) bool {
+	for _, w := range wanted {
+		if w == state {
+			return true
+		}
+	}
+	return false
+}
+
+// watchPodsForJob opens a follow-on watch on the pods of a Pending/Running
+// job, used to report richer transitions than the Job object alone offers.
+func watchPodsForJob(ctx context.Context, kt *KubernetesTracker, jobName string) (watch.Interface, error) {
+	pc := kt.clientSet.CoreV1().Pods(k8sDefaultNamespace)
+	return pc.Watch(ctx, k8sapi.ListOptions{LabelSelector: fmt.Sprintf("job-name=%s", jobName)})
+}
+
+// podObjectState reports a coarse DRMAA2 state for a single pod, used while
+// following a job-level Pending/Running transition via watchPodsForJob.
+func podObjectState(pod *corev1.Pod) drmaa2interface.JobState {
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return drmaa2interface.Done
+	case corev1.PodFailed:
+		return drmaa2interface.Failed
+	case corev1.PodRunning:
+		return drmaa2interface.Running
+	default:
+		return drmaa2interface.Queued
+	}
+}
@@ -0,0 +1,118 @@
+package kubernetestracker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgruber/drmaa2interface"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestJobObjectState(t *testing.T) {
+	testCases := []struct {
+		name  string
+		job   batchv1.Job
+		state drmaa2interface.JobState
+	}{
+		{"succeeded", batchv1.Job{Status: batchv1.JobStatus{Succeeded: 1}}, drmaa2interface.Done},
+		{"failed", batchv1.Job{Status: batchv1.JobStatus{Failed: 1}}, drmaa2interface.Failed},
+		{"active", batchv1.Job{Status: batchv1.JobStatus{Active: 1}}, drmaa2interface.Running},
+		{"none", batchv1.Job{}, drmaa2interface.Queued},
+	}
+	for _, tc := range testCases {
+		if got := jobObjectState(&tc.job); got != tc.state {
+			t.Errorf("%s: jobObjectState() = %v, want %v", tc.name, got, tc.state)
+		}
+	}
+}
+
+func TestPodObjectState(t *testing.T) {
+	testCases := []struct {
+		phase corev1.PodPhase
+		state drmaa2interface.JobState
+	}{
+		{corev1.PodSucceeded, drmaa2interface.Done},
+		{corev1.PodFailed, drmaa2interface.Failed},
+		{corev1.PodRunning, drmaa2interface.Running},
+		{corev1.PodPending, drmaa2interface.Queued},
+	}
+	for _, tc := range testCases {
+		pod := corev1.Pod{Status: corev1.PodStatus{Phase: tc.phase}}
+		if got := podObjectState(&pod); got != tc.state {
+			t.Errorf("phase %s: podObjectState() = %v, want %v", tc.phase, got, tc.state)
+		}
+	}
+}
+
+func TestWantsState(t *testing.T) {
+	wanted := []drmaa2interface.JobState{drmaa2interface.Done, drmaa2interface.Failed}
+	if !wantsState(wanted, drmaa2interface.Done) {
+		t.Error("expected Done to be wanted")
+	}
+	if wantsState(wanted, drmaa2interface.Running) {
+		t.Error("did not expect Running to be wanted")
+	}
+	if wantsState(nil, drmaa2interface.Undetermined) {
+		t.Error("an empty wanted list should never match")
+	}
+}
+
+func TestWaitOnJobEventsReachesWantedState(t *testing.T) {
+	w := watch.NewFake()
+	defer w.Stop()
+	go w.Modify(&batchv1.Job{Status: batchv1.JobStatus{Succeeded: 1}})
+
+	done, reached, needsBackoff, err := waitOnJobEvents(context.Background(), w, nil, []drmaa2interface.JobState{drmaa2interface.Done})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !done || !reached || needsBackoff {
+		t.Fatalf("done=%v reached=%v needsBackoff=%v, want true/true/false", done, reached, needsBackoff)
+	}
+}
+
+func TestWaitOnJobEventsDeleted(t *testing.T) {
+	// waitOnJobEvents always reports a Deleted job as done/!reached
+	// regardless of wanted - it is watchWaitForJob's job to decide whether
+	// Undetermined was requested and treat that as success instead of an
+	// error, so both cases below must produce the same result here.
+	testCases := []struct {
+		name   string
+		wanted []drmaa2interface.JobState
+	}{
+		{"without Undetermined requested", []drmaa2interface.JobState{drmaa2interface.Done}},
+		{"with Undetermined requested", []drmaa2interface.JobState{drmaa2interface.Undetermined}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := watch.NewFake()
+			defer w.Stop()
+			go w.Delete(&batchv1.Job{})
+
+			done, reached, needsBackoff, err := waitOnJobEvents(context.Background(), w, nil, tc.wanted)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if !done || reached || needsBackoff {
+				t.Fatalf("done=%v reached=%v needsBackoff=%v, want true/false/false", done, reached, needsBackoff)
+			}
+		})
+	}
+}
+
+func TestWaitOnJobEventsErrorTriggersBackoff(t *testing.T) {
+	w := watch.NewFake()
+	defer w.Stop()
+	go w.Error(&k8sapi.Status{Message: "connection reset"})
+
+	done, reached, needsBackoff, err := waitOnJobEvents(context.Background(), w, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if done || reached || !needsBackoff {
+		t.Fatalf("done=%v reached=%v needsBackoff=%v, want false/false/true", done, reached, needsBackoff)
+	}
+}